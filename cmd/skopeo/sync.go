@@ -1,28 +1,51 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/containers/image/v5/copy"
 	"github.com/containers/image/v5/directory"
 	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/archive"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v2"
 )
 
+// defaultRetryDelay is the base delay used for the exponential backoff
+// applied to retryable network errors encountered in the sync path, unless
+// overridden by --retry-delay or a per-registry retry.delay.
+const defaultRetryDelay = time.Second
+
+// maxRetryDelay caps the exponential backoff applied between retries.
+const maxRetryDelay = 60 * time.Second
+
 // syncOptions contains information retrieved from the skopeo sync command line.
 type syncOptions struct {
 	global            *globalOptions    // Global (not command dependant) skopeo options
@@ -33,13 +56,107 @@ type syncOptions struct {
 	source            string            // Source repository name
 	destination       string            // Destination registry name
 	scoped            bool              // When true, namespace copied images at destination using the source repository name
+	destOverwrite     bool              // When true, overwrite an existing docker-archive destination file
+	retryTimes        int               // Number of times to retry a transient network failure, in addition to the first attempt
+	retryDelay        time.Duration     // Base delay between retries, doubled (with jitter) on each subsequent attempt
+	all               bool              // Copy all of the images if an image in SOURCE is a manifest list
+	workers           int               // Number of concurrent image copies
+	failFast          bool              // Cancel all in-flight copies as soon as one of them fails
+	tagsRegex         string            // Only sync tags matching this regular expression (docker SOURCE only)
+	excludeTags       []string          // Skip tags matching any of these regular expressions (docker SOURCE only)
+	semverConstraint  string            // Only sync tags satisfying this semver constraint (docker SOURCE only)
+	keepLast          int               // Keep only the newest N tags, after the other filters (docker SOURCE only)
+	stateFile         string            // Path to a file recording successful copies, used to skip already-mirrored tags
+	dryRun            bool              // Report what would be copied or skipped, without copying or updating the state file
+}
+
+// tagFilter narrows down a list of tags to sync: tagsRegex (if set) selects
+// which tags are included in the first place, then excludeTags, semver and
+// keepLast are applied in that order to the result. It is shared by the YAML
+// registry selector (imageFilter) and the docker SOURCE CLI flags.
+type tagFilter struct {
+	TagsRegex   string
+	ExcludeTags []string
+	Semver      string
+	KeepLast    int
+}
+
+// retryOptions controls how transient network errors encountered while
+// listing tags or copying images in the sync path are retried.
+type retryOptions struct {
+	maxRetry int           // Number of retries after an initial failed attempt (0 disables retries)
+	delay    time.Duration // Base delay before the first retry
 }
 
 // repoDescriptor contains information of a single repository used as a sync source.
 type repoDescriptor struct {
 	DirBasePath  string                 // base path when source is 'dir'
 	TaggedImages []types.ImageReference // List of tagged image found for the repository
-	Context      *types.SystemContext   // SystemContext for the sync command
+	// TaggedInstances, if non-nil, is indexed like TaggedImages and lists, for
+	// each tagged image that is a manifest list or OCI index, the digests of
+	// the platform-specific instances selected by a registry's "platforms:"
+	// filter. A nil or empty entry means no such filtering applies, and
+	// --all/the default single-image selection is used instead.
+	TaggedInstances [][]digest.Digest
+	Context         *types.SystemContext // SystemContext for the sync command
+	Workers         int                  // Raises the shared copy pool size above --workers if larger; 0 means "use --workers". It does not limit this repository's own concurrency
+	StateFile       string               // Overrides --state-file for this repository's images, "" means "use --state-file"
+}
+
+// syncStateEntry records the result of the most recent successful copy of a
+// source image, keyed by its reference in the --state-file JSON object.
+type syncStateEntry struct {
+	SourceDigest string    `json:"source-digest"`
+	DestRef      string    `json:"dest-ref"`
+	DestDigest   string    `json:"dest-digest"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// syncState is the on-disk --state-file format: a source image reference to
+// the syncStateEntry recorded for it by the last successful copy.
+type syncState map[string]syncStateEntry
+
+// loadSyncState reads the state file at path, returning an empty syncState
+// if it does not exist yet.
+func loadSyncState(path string) (syncState, error) {
+	state := syncState{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, errors.Wrapf(err, fmt.Sprintf("Error reading state file %q", path))
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Invalid state file %q", path))
+	}
+	return state, nil
+}
+
+// save writes state to path as indented JSON.
+func (s syncState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// manifestDigest resolves the manifest digest that ref currently points to,
+// without pulling any layers. It is used both to check whether a source
+// image has changed since it was last copied, and, in place of a plain HEAD,
+// to confirm a previously-copied destination still has the expected content.
+func manifestDigest(ctx context.Context, sysCtx *types.SystemContext, ref types.ImageReference) (digest.Digest, error) {
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	manifestBlob, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(manifestBlob)
 }
 
 // tlsVerify is an implementation of the Unmarshaler interface, used to
@@ -51,10 +168,43 @@ type tlsVerifyConfig struct {
 // registrySyncConfig contains information about a single registry, read from
 // the source YAML file
 type registrySyncConfig struct {
-	Images      map[string]interface{} // Images map images name to slices or regular expression with the images' tags
+	Images      map[string]imageFilter // Images map images name to a tag selector
 	Credentials types.DockerAuthConfig // Username and password used to authenticate with the registry
 	TLSVerify   tlsVerifyConfig        `yaml:"tls-verify"` // TLS verification mode (enabled by default)
 	CertDir     string                 `yaml:"cert-dir"`   // Path to the TLS certificates of the registry
+	Retry       *retryConfig           `yaml:"retry"`      // Overrides --retry-times/--retry-delay for this registry
+	Platforms   []string               `yaml:"platforms"`  // If set, for manifest-list images, sync only the listed "os/arch[/variant]" platforms
+	Workers     int                    `yaml:"workers"`    // If larger than --workers, raises the size of the copy pool shared by all registries
+	StateFile   string                 `yaml:"state-file"` // Overrides --state-file for this registry
+}
+
+// retryConfig allows a registry entry in the YAML source config to override
+// the sync command's --retry-times and --retry-delay for itself.
+type retryConfig struct {
+	MaxRetry int    `yaml:"max-retry"`
+	Delay    string `yaml:"delay"` // a duration string, e.g. "2s"; parsed with time.ParseDuration
+}
+
+// effectiveRetryOptions merges the registry's retry config, if any, on top of
+// the sync command's default retry options.
+// It returns the effective retryOptions and any error encountered while
+// parsing the "delay" field.
+func (cfg *retryConfig) effectiveRetryOptions(defaults retryOptions) (retryOptions, error) {
+	opts := defaults
+	if cfg == nil {
+		return opts, nil
+	}
+	if cfg.MaxRetry > 0 {
+		opts.maxRetry = cfg.MaxRetry
+	}
+	if cfg.Delay != "" {
+		delay, err := time.ParseDuration(cfg.Delay)
+		if err != nil {
+			return opts, errors.Wrapf(err, fmt.Sprintf("Invalid retry delay %q", cfg.Delay))
+		}
+		opts.delay = delay
+	}
+	return opts, nil
 }
 
 // sourceConfig contains all registries information read from the source YAML file
@@ -77,7 +227,34 @@ func syncCmd(global *globalOptions) *cobra.Command {
 		Long: fmt.Sprint(`Copy all the images from a SOURCE to a DESTINATION.
 
 Allowed SOURCE transports (specified with --src): docker, dir, yaml.
-Allowed DESTINATION transports (specified with --dest): docker, dir.
+Allowed DESTINATION transports (specified with --dest): docker, dir, docker-archive.
+
+When the destination transport is docker-archive, all the synced images are
+written into a single tar file at DESTINATION instead of one directory or
+push per image; --dest-overwrite is required to replace an existing archive
+file.
+
+If a SOURCE tag is a manifest list, only the image matching the current
+system is synced unless --all is specified, in which case the whole list is
+copied; a yaml SOURCE can instead restrict a registry to specific platforms
+with the per-registry "platforms:" option.
+
+--workers controls how many tags are copied concurrently (also settable per
+registry in a yaml SOURCE), and defaults to 1 to preserve the historical
+sequential copy order. As of this release, a failing tag no longer aborts
+the sync by default: every tag is attempted and all failures are reported
+together at the end. Pass --fail-fast to restore the previous behavior of
+stopping at the first error.
+
+For a docker SOURCE, --tags-regex, --exclude-tags, --semver and --keep-last
+narrow down which tags are synced; a yaml SOURCE can express the same
+selection per image with a structured Images entry (tags, tags-regex,
+exclude-tags, semver, keep-last) in place of a plain tag list.
+
+--state-file records the source digest copied to each destination, and
+skips a tag on a later run if the source is unchanged and the destination
+still has the recorded digest; --dry-run reports what would be copied or
+skipped without copying anything or updating --state-file.
 
 See skopeo-sync(1) for details.
 `),
@@ -91,6 +268,18 @@ See skopeo-sync(1) for details.
 	flags.StringVarP(&opts.source, "src", "s", "", "SOURCE transport type")
 	flags.StringVarP(&opts.destination, "dest", "d", "", "DESTINATION transport type")
 	flags.BoolVar(&opts.scoped, "scoped", false, "Images at DESTINATION are prefix using the full source image path as scope")
+	flags.BoolVar(&opts.destOverwrite, "dest-overwrite", false, "Overwrite an existing docker-archive DESTINATION file")
+	flags.BoolVar(&opts.all, "all", false, "Copy all of the images if SOURCE-IMAGE is a list")
+	flags.IntVar(&opts.workers, "workers", 1, "Number of concurrent image copies")
+	flags.BoolVar(&opts.failFast, "fail-fast", false, "Abort all in-flight copies as soon as one of them fails, instead of continuing the rest")
+	flags.StringVar(&opts.tagsRegex, "tags-regex", "", "Sync only tags matching `REGEX`, for a docker SOURCE")
+	flags.StringArrayVar(&opts.excludeTags, "exclude-tags", []string{}, "Skip tags matching `REGEX`, for a docker SOURCE; may be repeated")
+	flags.StringVar(&opts.semverConstraint, "semver", "", "Sync only tags satisfying this semver `CONSTRAINT`, for a docker SOURCE")
+	flags.IntVar(&opts.keepLast, "keep-last", 0, "Keep only the `N` newest tags after the other filters, for a docker SOURCE")
+	flags.StringVar(&opts.stateFile, "state-file", "", "Record copies in `PATH` and skip any tag whose source digest is unchanged since the last successful copy, if the destination still has the recorded digest")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Report which tags would be copied or skipped, without copying anything or updating --state-file")
+	flags.IntVar(&opts.retryTimes, "retry-times", 0, "the number of times to possibly retry a transient network failure before giving up")
+	flags.DurationVar(&opts.retryDelay, "retry-delay", defaultRetryDelay, "the base `DELAY` between retries, increased exponentially with jitter on each attempt")
 	flags.AddFlagSet(&sharedFlags)
 	flags.AddFlagSet(&srcFlags)
 	flags.AddFlagSet(&destFlags)
@@ -111,6 +300,61 @@ func (tls *tlsVerifyConfig) UnmarshalYAML(unmarshal func(interface{}) error) err
 	return nil
 }
 
+// imageFilter is an Images map value: which tags of an image to sync. For
+// backwards compatibility it also accepts the two legacy forms it replaces,
+// a plain list of tags and a single tags-regex string.
+type imageFilter struct {
+	Tags        []string // Explicit tag list; if set, no tag-listing request is made
+	TagsRegex   string   // Only consider tags matching this regular expression
+	ExcludeTags []string // Drop tags matching any of these regular expressions
+	Semver      string   // Only consider tags satisfying this semver constraint
+	KeepLast    int      // Keep only the newest N tags, after the other filters
+}
+
+// UnmarshalYAML is the implementation of the Unmarshaler interface method for
+// the imageFilter type. It accepts a list of tags, a single regex string, or
+// a mapping with "tags", "tags-regex", "exclude-tags", "semver" and
+// "keep-last" keys.
+func (f *imageFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tagsRaw []interface{}
+	if err := unmarshal(&tagsRaw); err == nil {
+		tags := make([]string, 0, len(tagsRaw))
+		for _, tagValue := range tagsRaw {
+			switch tagValue.(type) {
+			case string, int, float64:
+				tags = append(tags, fmt.Sprintf("%v", tagValue))
+			default:
+				logrus.Errorf("Elements can only be strings if they are of type array, wrong value (%v|%T)", tagValue, tagValue)
+			}
+		}
+		f.Tags = tags
+		return nil
+	}
+
+	var tagsRegex string
+	if err := unmarshal(&tagsRegex); err == nil {
+		f.TagsRegex = tagsRegex
+		return nil
+	}
+
+	var selector struct {
+		Tags        []string `yaml:"tags"`
+		TagsRegex   string   `yaml:"tags-regex"`
+		ExcludeTags []string `yaml:"exclude-tags"`
+		Semver      string   `yaml:"semver"`
+		KeepLast    int      `yaml:"keep-last"`
+	}
+	if err := unmarshal(&selector); err != nil {
+		return errors.Wrap(err, "Images value must be a list of tags, a tags-regex string, or a selector mapping")
+	}
+	f.Tags = selector.Tags
+	f.TagsRegex = selector.TagsRegex
+	f.ExcludeTags = selector.ExcludeTags
+	f.Semver = selector.Semver
+	f.KeepLast = selector.KeepLast
+	return nil
+}
+
 // newSourceConfig unmarshals the provided YAML file path to the sourceConfig type.
 // It returns a new unmarshaled sourceConfig object and any error encountered.
 func newSourceConfig(yamlFile string) (sourceConfig, error) {
@@ -127,9 +371,13 @@ func newSourceConfig(yamlFile string) (sourceConfig, error) {
 }
 
 // destinationReference creates an image reference using the provided transport.
+// If dryRun is true, it skips the "dir" transport's overwrite check and
+// directory creation, since a dry run must not touch the filesystem; the
+// returned reference is only used for reporting in that case, never as an
+// actual copy destination.
 // It returns a image reference to be used as destination of an image copy and
 // any error encountered.
-func destinationReference(destination string, transport string) (types.ImageReference, error) {
+func destinationReference(destination string, transport string, dryRun bool) (types.ImageReference, error) {
 	var imageTransport types.ImageTransport
 
 	switch transport {
@@ -137,17 +385,19 @@ func destinationReference(destination string, transport string) (types.ImageRefe
 		destination = fmt.Sprintf("//%s", destination)
 		imageTransport = docker.Transport
 	case directory.Transport.Name():
-		_, err := os.Stat(destination)
-		if err == nil {
-			return nil, errors.Errorf(fmt.Sprintf("Refusing to overwrite destination directory %q", destination))
-		}
-		if !os.IsNotExist(err) {
-			return nil, errors.Wrap(err, "Destination directory could not be used")
-		}
-		// the directory holding the image must be created here
-		if err = os.MkdirAll(destination, 0755); err != nil {
-			return nil, errors.Wrapf(err, fmt.Sprintf("Error creating directory for image %s",
-				destination))
+		if !dryRun {
+			_, err := os.Stat(destination)
+			if err == nil {
+				return nil, errors.Errorf(fmt.Sprintf("Refusing to overwrite destination directory %q", destination))
+			}
+			if !os.IsNotExist(err) {
+				return nil, errors.Wrap(err, "Destination directory could not be used")
+			}
+			// the directory holding the image must be created here
+			if err = os.MkdirAll(destination, 0755); err != nil {
+				return nil, errors.Wrapf(err, fmt.Sprintf("Error creating directory for image %s",
+					destination))
+			}
 		}
 		imageTransport = directory.Transport
 	default:
@@ -163,15 +413,153 @@ func destinationReference(destination string, transport string) (types.ImageRefe
 	return destRef, nil
 }
 
+// destinationArchiveReference creates an image reference within the given
+// docker-archive Writer for a single image being synced. destSuffix is the
+// repo:tag computed for that image by opts.run (the source's DockerReference
+// when available, or a synthesized repo:tag when the source is a local
+// directory).
+// It returns a image reference to be used as destination of an image copy and
+// any error encountered.
+func destinationArchiveReference(writer *archive.Writer, destSuffix string) (types.ImageReference, error) {
+	// destSuffix is computed for use as a filesystem path fragment (it may,
+	// e.g., start with "/" for a --scoped "dir" SOURCE with sub-paths), but
+	// ParseNormalizedNamed requires a reference name instead, which rejects a
+	// leading "/" as an empty path component; normalize before parsing.
+	repoPath := path.Clean(strings.TrimPrefix(destSuffix, "/"))
+	if repoPath == "" || repoPath == "." {
+		repoPath = "image"
+	}
+
+	namedTagged, err := reference.ParseNormalizedNamed(repoPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Cannot obtain a valid image reference for transport %q and reference %q", archive.Transport.Name(), repoPath))
+	}
+	tagged, ok := namedTagged.(reference.NamedTagged)
+	if !ok {
+		tagged, err = reference.WithTag(namedTagged, "latest")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	destRef, err := archive.NewReference(writer, tagged)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Cannot obtain a valid image reference for transport %q and reference %q", archive.Transport.Name(), repoPath))
+	}
+	return destRef, nil
+}
+
+// isRetryableSyncError returns true if err is likely a transient failure
+// (network timeouts, HTTP 429/5xx from the registry, EOFs mid-transfer) that
+// is worth retrying, as opposed to a permanent failure (authentication
+// failures other than a 401 on tag listing, manifest-unknown, policy
+// rejection) that would just fail again.
+func isRetryableSyncError(err error) bool {
+	if err == nil {
+		return false
+	}
+	err = errors.Cause(err)
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if netErr, ok := err.(net.Error); ok {
+		return netErr.Timeout() || netErr.Temporary()
+	}
+	if errs, ok := err.(errcode.Errors); ok {
+		for _, e := range errs {
+			if isRetryableSyncError(e) {
+				return true
+			}
+		}
+		return false
+	}
+	if coded, ok := err.(errcode.ErrorCoder); ok {
+		switch coded.ErrorCode() {
+		case errcode.ErrorCodeUnauthorized, errcode.ErrorCodeDenied, errcode.ErrorCodeUnsupported:
+			return false
+		}
+		// Registries surface a 404 for a deleted or typo'd tag as a coded
+		// error (e.g. v2.ErrorCodeManifestUnknown) rather than a plain
+		// string, so the "manifest unknown" check below would never see it;
+		// check the message here too before falling through to "retryable".
+		if strings.Contains(strings.ToLower(err.Error()), "manifest unknown") {
+			return false
+		}
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "manifest unknown"),
+		strings.Contains(msg, "unauthorized"),
+		strings.Contains(msg, "authentication required"),
+		strings.Contains(msg, "access denied"),
+		strings.Contains(msg, "policy"):
+		return false
+	case strings.Contains(msg, fmt.Sprintf("%d", http.StatusTooManyRequests)),
+		strings.Contains(msg, "too many requests"),
+		strings.Contains(msg, "connection reset"),
+		strings.Contains(msg, "timeout"),
+		strings.Contains(msg, "eof"):
+		return true
+	}
+	for code := http.StatusInternalServerError; code <= http.StatusNetworkAuthenticationRequired; code++ {
+		if strings.Contains(msg, fmt.Sprintf("%d", code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelayWithJitter computes how long to sleep before attempt (1-indexed),
+// using exponential backoff with jitter: the delay doubles on each attempt,
+// is capped at maxRetryDelay, and is randomized between 50% and 100% of that
+// value so that concurrent syncs against the same flaky mirror don't retry
+// in lockstep.
+func retryDelayWithJitter(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = defaultRetryDelay
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryOnError calls op, and, while it returns a retryable error (per
+// isRetryableSyncError), retries it up to opts.maxRetry additional times with
+// exponential backoff and jitter, logging "retry N/M after err: …" progress
+// messages. descr identifies the operation in those messages.
+func retryOnError(opts retryOptions, descr string, op func() error) error {
+	err := op()
+	for attempt := 1; err != nil && attempt <= opts.maxRetry && isRetryableSyncError(err); attempt++ {
+		delay := retryDelayWithJitter(opts.delay, attempt)
+		logrus.Warnf("%s: retry %d/%d after err: %v", descr, attempt, opts.maxRetry, err)
+		time.Sleep(delay)
+		err = op()
+	}
+	return err
+}
+
 // getImageTags retrieves all the tags associated to an image hosted on a
 // container registry.
 // It returns a string slice of tags and any error encountered.
-func getImageTags(ctx context.Context, sysCtx *types.SystemContext, imgRef types.ImageReference) ([]string, error) {
+func getImageTags(ctx context.Context, sysCtx *types.SystemContext, imgRef types.ImageReference, retryOpts retryOptions) ([]string, error) {
 	name := imgRef.DockerReference().Name()
 	logrus.WithFields(logrus.Fields{
 		"image": name,
 	}).Info("Getting tags")
-	tags, err := docker.GetRepositoryTags(ctx, sysCtx, imgRef)
+	var tags []string
+	err := retryOnError(retryOpts, fmt.Sprintf("Getting tags for %s", name), func() error {
+		var err error
+		tags, err = docker.GetRepositoryTags(ctx, sysCtx, imgRef)
+		return err
+	})
 
 	switch err := err.(type) {
 	case nil:
@@ -188,6 +576,84 @@ func getImageTags(ctx context.Context, sysCtx *types.SystemContext, imgRef types
 	return tags, nil
 }
 
+// parsePlatform splits a platform in "os/arch[/variant]" form, as accepted by
+// a registry's "platforms:" list, into its components.
+func parsePlatform(platform string) (os, arch, variant string, err error) {
+	parts := strings.SplitN(platform, "/", 3)
+	if len(parts) < 2 {
+		return "", "", "", errors.Errorf("invalid platform %q, expected OS/ARCH[/VARIANT]", platform)
+	}
+	os, arch = parts[0], parts[1]
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return os, arch, variant, nil
+}
+
+// instancesForPlatforms inspects ref's manifest and, if it is a manifest list
+// or OCI index, returns the digest of the instance matching each of the given
+// platforms. Platforms with no matching instance are skipped with a warning.
+// If ref's manifest is a single image, it returns (nil, nil): there is
+// nothing to filter.
+func instancesForPlatforms(ctx context.Context, sysCtx *types.SystemContext, ref types.ImageReference, platforms []string) ([]digest.Digest, error) {
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Error reading image %q", transports.ImageName(ref)))
+	}
+	defer src.Close()
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Error reading manifest of %q", transports.ImageName(ref)))
+	}
+	if !manifest.MIMETypeIsMultiImage(mimeType) {
+		return nil, nil
+	}
+
+	var instances []digest.Digest
+	for _, platform := range platforms {
+		os, arch, variant, err := parsePlatform(platform)
+		if err != nil {
+			return nil, err
+		}
+		platformCtx := *sysCtx
+		platformCtx.OSChoice = os
+		platformCtx.ArchitectureChoice = arch
+		platformCtx.VariantChoice = variant
+
+		var instanceDigest digest.Digest
+		switch mimeType {
+		case manifest.DockerV2ListMediaType:
+			list, err := manifest.Schema2ListFromManifest(rawManifest)
+			if err != nil {
+				return nil, err
+			}
+			instanceDigest, err = list.ChooseInstance(&platformCtx)
+			if err != nil {
+				logrus.Warnf("Platform %q not found in %q, skipping", platform, transports.ImageName(ref))
+				continue
+			}
+		case imgspecv1.MediaTypeImageIndex:
+			index, err := manifest.OCI1IndexFromManifest(rawManifest)
+			if err != nil {
+				return nil, err
+			}
+			instanceDigest, err = index.ChooseInstance(&platformCtx)
+			if err != nil {
+				logrus.Warnf("Platform %q not found in %q, skipping", platform, transports.ImageName(ref))
+				continue
+			}
+		default:
+			return nil, errors.Errorf("Unsupported manifest list type %q for %q", mimeType, transports.ImageName(ref))
+		}
+		instances = append(instances, instanceDigest)
+	}
+	if len(instances) == 0 {
+		return nil, errors.Errorf("none of the requested platforms (%s) were found in %q", strings.Join(platforms, ", "), transports.ImageName(ref))
+	}
+	return instances, nil
+}
+
 // isTagSpecified checks if an image name includes a tag and returns any errors
 // encountered.
 func isTagSpecified(imageName string) (bool, error) {
@@ -204,13 +670,133 @@ func isTagSpecified(imageName string) (bool, error) {
 	return tagged, nil
 }
 
+// applyTagFilters narrows tags down to the ones that should be synced:
+// filter.TagsRegex (if set) first selects which tags are kept, filter.ExcludeTags
+// regexes then drop any of those tags, filter.Semver (if set) keeps only tags
+// that parse as semver and satisfy the constraint, and finally filter.KeepLast
+// (if set) sorts the remaining tags by version, descending, and truncates to
+// that many entries. Each step is a no-op when its corresponding field is unset.
+func applyTagFilters(tags []string, filter tagFilter) ([]string, error) {
+	if filter.TagsRegex != "" {
+		tagReg, err := regexp.Compile(filter.TagsRegex)
+		if err != nil {
+			return nil, errors.Wrapf(err, fmt.Sprintf("Invalid tags-regex %q", filter.TagsRegex))
+		}
+		var matched []string
+		for _, tag := range tags {
+			if tagReg.MatchString(tag) {
+				matched = append(matched, tag)
+			}
+		}
+		tags = matched
+	}
+
+	for _, exclude := range filter.ExcludeTags {
+		excludeReg, err := regexp.Compile(exclude)
+		if err != nil {
+			return nil, errors.Wrapf(err, fmt.Sprintf("Invalid exclude-tags regex %q", exclude))
+		}
+		var kept []string
+		for _, tag := range tags {
+			if !excludeReg.MatchString(tag) {
+				kept = append(kept, tag)
+			}
+		}
+		tags = kept
+	}
+
+	if filter.Semver != "" {
+		constraint, err := semver.NewConstraint(filter.Semver)
+		if err != nil {
+			return nil, errors.Wrapf(err, fmt.Sprintf("Invalid semver constraint %q", filter.Semver))
+		}
+		var kept []string
+		for _, tag := range tags {
+			version, err := semver.NewVersion(tag)
+			if err != nil {
+				// Not a semver tag at all (e.g. "latest", "sha-abcdef"): skip it.
+				continue
+			}
+			if constraint.Check(version) {
+				kept = append(kept, tag)
+			}
+		}
+		tags = kept
+	}
+
+	if filter.KeepLast > 0 {
+		sortTagsByVersionDescending(tags)
+		if len(tags) > filter.KeepLast {
+			tags = tags[:filter.KeepLast]
+		}
+	}
+
+	return tags, nil
+}
+
+// sortTagsByVersionDescending sorts tags so that the newest comes first:
+// valid semver tags sort above non-semver ones and are compared as versions;
+// ties and non-semver tags fall back to a reverse lexical sort.
+func sortTagsByVersionDescending(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		vi, erri := semver.NewVersion(tags[i])
+		vj, errj := semver.NewVersion(tags[j])
+		switch {
+		case erri == nil && errj == nil:
+			if vi.Equal(vj) {
+				return tags[i] > tags[j]
+			}
+			return vi.GreaterThan(vj)
+		case erri == nil:
+			return true
+		case errj == nil:
+			return false
+		default:
+			return tags[i] > tags[j]
+		}
+	})
+}
+
+// filterTaggedImages applies filter to a list of already-tagged image
+// references, by extracting each reference's tag and delegating to
+// applyTagFilters. It is used to let an ad-hoc "docker" SOURCE benefit from
+// the same --tags-regex/--exclude-tags/--semver/--keep-last filtering as a
+// YAML registry's structured Images selector.
+func filterTaggedImages(refs []types.ImageReference, filter tagFilter) ([]types.ImageReference, error) {
+	if filter.TagsRegex == "" && len(filter.ExcludeTags) == 0 && filter.Semver == "" && filter.KeepLast == 0 {
+		return refs, nil
+	}
+
+	tags := make([]string, 0, len(refs))
+	refForTag := make(map[string]types.ImageReference, len(refs))
+	for _, ref := range refs {
+		tagged, ok := ref.DockerReference().(reference.NamedTagged)
+		if !ok {
+			continue
+		}
+		tags = append(tags, tagged.Tag())
+		refForTag[tagged.Tag()] = ref
+	}
+
+	tags, err := applyTagFilters(tags, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]types.ImageReference, 0, len(tags))
+	for _, tag := range tags {
+		filtered = append(filtered, refForTag[tag])
+	}
+	return filtered, nil
+}
+
 // imagesTopCopyFromRepo builds a list of image references from the tags
 // found in the source repository.
 // It returns an image reference slice with as many elements as the tags found
 // and any error encountered.
-func imagesToCopyFromRepo(repoReference types.ImageReference, repoName string, sourceCtx *types.SystemContext) ([]types.ImageReference, error) {
+func imagesToCopyFromRepo(repoReference types.ImageReference, repoName string, sourceCtx *types.SystemContext, retryOpts retryOptions) ([]types.ImageReference, error) {
 	var sourceReferences []types.ImageReference
-	tags, err := getImageTags(context.Background(), sourceCtx, repoReference)
+	tags, err := getImageTags(context.Background(), sourceCtx, repoReference, retryOpts)
 	if err != nil {
 		return sourceReferences, err
 	}
@@ -261,7 +847,12 @@ func imagesToCopyFromDir(dirPath string) ([]types.ImageReference, error) {
 // It returns a repository descriptors slice with as many elements as the images
 // found and any error encountered. Each element of the slice is a list of
 // tagged image references, to be used as sync source.
-func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourceCtx types.SystemContext) ([]repoDescriptor, error) {
+func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourceCtx types.SystemContext, defaultRetry retryOptions) ([]repoDescriptor, error) {
+	retryOpts, err := cfg.Retry.effectiveRetryOptions(defaultRetry)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("Failed to parse retry options for registry %q", registryName))
+	}
+
 	var repoDescList []repoDescriptor
 	for imageName, tags := range cfg.Images {
 		repoName := fmt.Sprintf("//%s", path.Join(registryName, imageName))
@@ -280,97 +871,22 @@ func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourc
 
 		var sourceReferences []types.ImageReference
 
-		switch tags.(type) {
-		case []string, []interface{}, nil:
-			tagList := make([]string, 0)
-			if tagIns, ok := tags.([]interface{}); ok {
-				for _, tagValue := range tagIns {
-					switch tagValue.(type) {
-					case string, int, float64:
-						tagList = append(tagList, fmt.Sprintf("%v", tagValue))
-					default:
-						logrus.WithFields(logrus.Fields{
-							"repo":     imageName,
-							"registry": registryName,
-						}).Error("Error processing repo, skipping")
-						logrus.Errorf("Elements can only be strings if they are of type array, wrong value (%v|%T)", tagValue, tagValue)
-						continue
-					}
-				}
-			} else {
-				// nil is equl full tags
-				if tags != nil {
-					tagList = tags.([]string)
-				}
-			}
-
-			for _, tag := range tagList {
-				source := fmt.Sprintf("%s:%s", repoName, tag)
-
-				imageRef, err := docker.ParseReference(source)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"tag": source,
-					}).Error("Error processing tag, skipping")
-					logrus.Errorf("Error getting image reference: %s", err)
-					continue
-				}
-				sourceReferences = append(sourceReferences, imageRef)
-			}
-
-			if len(tagList) == 0 {
-				logrus.WithFields(logrus.Fields{
-					"repo":     imageName,
-					"registry": registryName,
-				}).Info("Querying registry for image tags")
-
-				imageRef, err := docker.ParseReference(repoName)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"repo":     imageName,
-						"registry": registryName,
-					}).Error("Error processing repo, skipping")
-					logrus.Error(err)
-					continue
-				}
-
-				sourceReferences, err = imagesToCopyFromRepo(imageRef, repoName, serverCtx)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"repo":     imageName,
-						"registry": registryName,
-					}).Error("Error processing repo, skipping")
-					logrus.Error(err)
-					continue
-				}
-			}
-
-		case string:
-			tagReg, err := regexp.Compile(tags.(string))
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"repo":     imageName,
-					"registry": registryName,
-				}).Error("Error processing repo, skipping")
-				logrus.Error(err)
-			}
-
+		var tagList []string
+		if len(tags.Tags) > 0 {
+			tagList = tags.Tags
+		} else {
 			logrus.WithFields(logrus.Fields{
 				"repo":     imageName,
 				"registry": registryName,
 			}).Info("Querying registry for image tags")
 
+			// getImageTags already retries internally; don't wrap it in
+			// another retryOnError here, or a persistently-failing registry
+			// gets two nested, overlapping backoff cycles instead of one.
 			imageRef, err := docker.ParseReference(repoName)
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"repo":     imageName,
-					"registry": registryName,
-				}).Error("Error processing repo, skipping")
-				logrus.Error(err)
-				continue
+			if err == nil {
+				tagList, err = getImageTags(context.Background(), serverCtx, imageRef, retryOpts)
 			}
-
-			allSourceReferences, err := imagesToCopyFromRepo(imageRef, repoName, serverCtx)
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
 					"repo":     imageName,
@@ -379,27 +895,44 @@ func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourc
 				logrus.Error(err)
 				continue
 			}
+		}
 
-			logrus.WithFields(logrus.Fields{
-				"repo":     imageName,
-				"registry": registryName,
-			}).Infof("Start filtering using the regular expression: %v", tags.(string))
-			for _, sReference := range allSourceReferences {
-				// get the tag names to match, [1] default is "latest" by .DockerReference().String()
-				if tagReg.Match([]byte(strings.Split(sReference.DockerReference().String(), ":")[1])) {
-					sourceReferences = append(sourceReferences, sReference)
-				}
-			}
+		// tags-regex only makes sense against the full tag list; an
+		// explicit Tags list is already exactly what the user wants.
+		regexForInclude := tags.TagsRegex
+		if len(tags.Tags) > 0 {
+			regexForInclude = ""
+		}
 
-		default:
+		tagList, err := applyTagFilters(tagList, tagFilter{
+			TagsRegex:   regexForInclude,
+			ExcludeTags: tags.ExcludeTags,
+			Semver:      tags.Semver,
+			KeepLast:    tags.KeepLast,
+		})
+		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"repo":     imageName,
 				"registry": registryName,
 			}).Error("Error processing repo, skipping")
-			logrus.Errorf("Tags's type only support []string or regular expression string, wrong type:(%v %T)", tags, tags)
+			logrus.Error(err)
 			continue
 		}
 
+		for _, tag := range tagList {
+			source := fmt.Sprintf("%s:%s", repoName, tag)
+
+			imageRef, err := docker.ParseReference(source)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"tag": source,
+				}).Error("Error processing tag, skipping")
+				logrus.Errorf("Error getting image reference: %s", err)
+				continue
+			}
+			sourceReferences = append(sourceReferences, imageRef)
+		}
+
 		if len(sourceReferences) == 0 {
 			logrus.WithFields(logrus.Fields{
 				"repo":     imageName,
@@ -407,9 +940,47 @@ func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourc
 			}).Warnf("No tags to sync found")
 			continue
 		}
+
+		var taggedInstances [][]digest.Digest
+		if len(cfg.Platforms) > 0 {
+			// instancesForPlatforms fails when a tag is a manifest list but
+			// none of the requested platforms matched any of its instances;
+			// drop that tag entirely rather than let it fall through to the
+			// default "copy everything" selection, which is exactly what
+			// "platforms:" is meant to prevent.
+			filteredReferences := sourceReferences[:0]
+			var filteredInstances [][]digest.Digest
+			for _, ref := range sourceReferences {
+				instances, err := instancesForPlatforms(context.Background(), serverCtx, ref, cfg.Platforms)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"repo":     imageName,
+						"registry": registryName,
+					}).Errorf("Error filtering platforms for %q, skipping tag", transports.ImageName(ref))
+					logrus.Error(err)
+					continue
+				}
+				filteredReferences = append(filteredReferences, ref)
+				filteredInstances = append(filteredInstances, instances)
+			}
+			sourceReferences = filteredReferences
+			taggedInstances = filteredInstances
+
+			if len(sourceReferences) == 0 {
+				logrus.WithFields(logrus.Fields{
+					"repo":     imageName,
+					"registry": registryName,
+				}).Warnf("No tags left to sync after platform filtering")
+				continue
+			}
+		}
+
 		repoDescList = append(repoDescList, repoDescriptor{
-			TaggedImages: sourceReferences,
-			Context:      serverCtx})
+			TaggedImages:    sourceReferences,
+			TaggedInstances: taggedInstances,
+			Context:         serverCtx,
+			Workers:         cfg.Workers,
+			StateFile:       cfg.StateFile})
 	}
 
 	return repoDescList, nil
@@ -420,7 +991,7 @@ func imagesToCopyFromRegistry(registryName string, cfg registrySyncConfig, sourc
 // It returns a slice of repository descriptors, where each descriptor is a
 // list of tagged image references to be used as sync source, and any error
 // encountered.
-func imagesToCopy(source string, transport string, sourceCtx *types.SystemContext) ([]repoDescriptor, error) {
+func imagesToCopy(source string, transport string, sourceCtx *types.SystemContext, retryOpts retryOptions, filter tagFilter) ([]repoDescriptor, error) {
 	var descriptors []repoDescriptor
 
 	switch transport {
@@ -447,11 +1018,17 @@ func imagesToCopy(source string, transport string, sourceCtx *types.SystemContex
 		desc.TaggedImages, err = imagesToCopyFromRepo(
 			srcRef,
 			fmt.Sprintf("//%s", source),
-			sourceCtx)
+			sourceCtx,
+			retryOpts)
 
 		if err != nil {
 			return descriptors, err
 		}
+
+		desc.TaggedImages, err = filterTaggedImages(desc.TaggedImages, filter)
+		if err != nil {
+			return descriptors, err
+		}
 		if len(desc.TaggedImages) == 0 {
 			return descriptors, errors.Errorf("No images to sync found in %q", source)
 		}
@@ -489,7 +1066,7 @@ func imagesToCopy(source string, transport string, sourceCtx *types.SystemContex
 				continue
 			}
 
-			descs, err := imagesToCopyFromRegistry(registryName, registryConfig, *sourceCtx)
+			descs, err := imagesToCopyFromRegistry(registryName, registryConfig, *sourceCtx, retryOpts)
 			if err != nil {
 				return descriptors, errors.Wrapf(err, "Failed to retrieve list of images from registry %q", registryName)
 			}
@@ -531,7 +1108,7 @@ func (opts *syncOptions) run(args []string, stdout io.Writer) error {
 	if len(opts.destination) == 0 {
 		return errors.New("A destination transport must be specified")
 	}
-	if !contains(opts.destination, []string{docker.Transport.Name(), directory.Transport.Name()}) {
+	if !contains(opts.destination, []string{docker.Transport.Name(), directory.Transport.Name(), archive.Transport.Name()}) {
 		return errors.Errorf("%q is not a valid destination transport", opts.destination)
 	}
 
@@ -544,8 +1121,16 @@ func (opts *syncOptions) run(args []string, stdout io.Writer) error {
 		return err
 	}
 
+	retryOpts := retryOptions{maxRetry: opts.retryTimes, delay: opts.retryDelay}
+	cliTagFilter := tagFilter{
+		TagsRegex:   opts.tagsRegex,
+		ExcludeTags: opts.excludeTags,
+		Semver:      opts.semverConstraint,
+		KeepLast:    opts.keepLast,
+	}
+
 	sourceArg := args[0]
-	srcRepoList, err := imagesToCopy(sourceArg, opts.source, sourceCtx)
+	srcRepoList, err := imagesToCopy(sourceArg, opts.source, sourceCtx, retryOpts, cliTagFilter)
 	if err != nil {
 		return err
 	}
@@ -559,6 +1144,26 @@ func (opts *syncOptions) run(args []string, stdout io.Writer) error {
 	ctx, cancel := opts.global.commandTimeoutContext()
 	defer cancel()
 
+	// When syncing into a single docker-archive file, all the images share one
+	// archive.Writer so that they end up as a single tarball instead of one
+	// archive per image.
+	var archiveWriter *archive.Writer
+	if !opts.dryRun && opts.destination == archive.Transport.Name() {
+		if _, err := os.Stat(destination); err == nil {
+			if !opts.destOverwrite {
+				return errors.Errorf("Refusing to overwrite destination file %q without --dest-overwrite", destination)
+			}
+		} else if !os.IsNotExist(err) {
+			return errors.Wrap(err, "Destination file could not be used")
+		}
+
+		archiveWriter, err = archive.NewWriter(destinationCtx, destination)
+		if err != nil {
+			return errors.Wrapf(err, fmt.Sprintf("Error opening archive %q", destination))
+		}
+		defer archiveWriter.Close()
+	}
+
 	imagesNumber := 0
 	options := copy.Options{
 		RemoveSignatures: opts.removeSignatures,
@@ -566,46 +1171,273 @@ func (opts *syncOptions) run(args []string, stdout io.Writer) error {
 		ReportWriter:     os.Stdout,
 		DestinationCtx:   destinationCtx,
 	}
+	if opts.all {
+		options.ImageListSelection = copy.CopyAllImages
+	}
+
+	// The pool is sized to the largest of --workers and any per-registry
+	// "workers:" override: a single shared pool keeps the total number of
+	// concurrent copies bounded, since jobs from every source repository are
+	// interleaved onto it regardless of which registry asked for more workers.
+	workers := opts.workers
+	for _, srcRepo := range srcRepoList {
+		if srcRepo.Workers > workers {
+			workers = srcRepo.Workers
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var g *errgroup.Group
+	copyCtx := ctx
+	if opts.failFast {
+		g, copyCtx = errgroup.WithContext(ctx)
+	} else {
+		g = &errgroup.Group{}
+	}
+	g.SetLimit(workers)
 
+	// Load every --state-file / per-registry "state-file:" up front, so the
+	// per-image goroutines below only ever touch the already-parsed
+	// in-memory states, guarded by stateMu.
+	stateByFile := map[string]syncState{}
 	for _, srcRepo := range srcRepoList {
-		options.SourceCtx = srcRepo.Context
+		statePath := srcRepo.StateFile
+		if statePath == "" {
+			statePath = opts.stateFile
+		}
+		if statePath == "" || stateByFile[statePath] != nil {
+			continue
+		}
+		state, err := loadSyncState(statePath)
+		if err != nil {
+			return err
+		}
+		stateByFile[statePath] = state
+	}
+
+	var (
+		countersMu    sync.Mutex
+		stdoutMu      sync.Mutex
+		archiveMu     sync.Mutex
+		stateMu       sync.Mutex
+		copyErrors    []error
+		imagesSkipped int
+	)
+
+	// recordErr adds err to copyErrors, so that the final report lists every
+	// failed tag, not only those whose copy.Image call failed.
+	recordErr := func(err error) error {
+		countersMu.Lock()
+		copyErrors = append(copyErrors, err)
+		countersMu.Unlock()
+		return err
+	}
+
+	for _, srcRepo := range srcRepoList {
+		srcRepo := srcRepo
+		imageOptionsBase := options
+		imageOptionsBase.SourceCtx = srcRepo.Context
+
 		for counter, ref := range srcRepo.TaggedImages {
-			var destSuffix string
-			switch ref.Transport() {
-			case docker.Transport:
-				// docker -> dir or docker -> docker
-				destSuffix = ref.DockerReference().String()
-			case directory.Transport:
-				// dir -> docker (we don't allow `dir` -> `dir` sync operations)
-				destSuffix = strings.TrimPrefix(ref.StringWithinTransport(), srcRepo.DirBasePath)
-				if destSuffix == "" {
-					// if source is a full path to an image, have destPath scoped to repo:tag
-					destSuffix = path.Base(srcRepo.DirBasePath)
-				}
+			ref := ref
+			counter := counter
+			imageOptions := imageOptionsBase
+			if counter < len(srcRepo.TaggedInstances) && len(srcRepo.TaggedInstances[counter]) > 0 {
+				imageOptions.ImageListSelection = copy.CopySpecificImages
+				imageOptions.Instances = srcRepo.TaggedInstances[counter]
 			}
 
-			if !opts.scoped {
-				destSuffix = path.Base(destSuffix)
-			}
+			g.Go(func() error {
+				var destSuffix string
+				switch ref.Transport() {
+				case docker.Transport:
+					// docker -> dir or docker -> docker
+					destSuffix = ref.DockerReference().String()
+				case directory.Transport:
+					// dir -> docker (we don't allow `dir` -> `dir` sync operations)
+					destSuffix = strings.TrimPrefix(ref.StringWithinTransport(), srcRepo.DirBasePath)
+					if destSuffix == "" {
+						// if source is a full path to an image, have destPath scoped to repo:tag
+						destSuffix = path.Base(srcRepo.DirBasePath)
+					}
+				}
 
-			destRef, err := destinationReference(path.Join(destination, destSuffix), opts.destination)
-			if err != nil {
-				return err
-			}
+				if !opts.scoped {
+					destSuffix = path.Base(destSuffix)
+				}
 
-			logrus.WithFields(logrus.Fields{
-				"from": transports.ImageName(ref),
-				"to":   transports.ImageName(destRef),
-			}).Infof("Copying image tag %d/%d", counter+1, len(srcRepo.TaggedImages))
+				if archiveWriter == nil && opts.destination == archive.Transport.Name() {
+					// Only reachable in a dry run: outside of one, archiveWriter is
+					// always already open for a docker-archive destination (see
+					// above). There's no archive file to build a reference against
+					// without creating it, so just report the tag without touching
+					// the filesystem or opening the archive.
+					sourceName := transports.ImageName(ref)
+					destName := fmt.Sprintf("%s:%s", destination, destSuffix)
+					logrus.WithFields(logrus.Fields{"from": sourceName, "to": destName}).Infof("Would copy image tag %d/%d (dry run)", counter+1, len(srcRepo.TaggedImages))
+					countersMu.Lock()
+					imagesNumber++
+					countersMu.Unlock()
+					return nil
+				}
 
-			_, err = copy.Image(ctx, policyContext, destRef, ref, &options)
-			if err != nil {
-				return errors.Wrapf(err, fmt.Sprintf("Error copying tag %q", transports.ImageName(ref)))
+				var destRef types.ImageReference
+				var err error
+				if archiveWriter != nil {
+					destRef, err = destinationArchiveReference(archiveWriter, destSuffix)
+				} else {
+					destRef, err = destinationReference(path.Join(destination, destSuffix), opts.destination, opts.dryRun)
+				}
+				if err != nil {
+					return recordErr(err)
+				}
+
+				sourceName := transports.ImageName(ref)
+				destName := transports.ImageName(destRef)
+				logFields := logrus.Fields{"from": sourceName, "to": destName}
+
+				statePath := srcRepo.StateFile
+				if statePath == "" {
+					statePath = opts.stateFile
+				}
+
+				var sourceDigest digest.Digest
+				if statePath != "" {
+					err = retryOnError(retryOpts, fmt.Sprintf("Computing source manifest digest for %s", sourceName), func() error {
+						var err error
+						sourceDigest, err = manifestDigest(copyCtx, srcRepo.Context, ref)
+						return err
+					})
+					if err != nil {
+						return recordErr(errors.Wrapf(err, fmt.Sprintf("Error computing source manifest digest for %q", sourceName)))
+					}
+
+					stateMu.Lock()
+					entry, recorded := stateByFile[statePath][sourceName]
+					stateMu.Unlock()
+
+					if recorded && entry.DestRef == destName && entry.SourceDigest == sourceDigest.String() {
+						var destDigest digest.Digest
+						err := retryOnError(retryOpts, fmt.Sprintf("Computing destination manifest digest for %s", destName), func() error {
+							var err error
+							destDigest, err = manifestDigest(copyCtx, destinationCtx, destRef)
+							return err
+						})
+						if err == nil && destDigest.String() == entry.DestDigest {
+							logrus.WithFields(logFields).Info("Already in sync, skipping")
+							countersMu.Lock()
+							imagesSkipped++
+							countersMu.Unlock()
+							return nil
+						}
+					}
+				}
+
+				if opts.dryRun {
+					logrus.WithFields(logFields).Infof("Would copy image tag %d/%d (dry run)", counter+1, len(srcRepo.TaggedImages))
+					countersMu.Lock()
+					imagesNumber++
+					countersMu.Unlock()
+					return nil
+				}
+
+				logrus.WithFields(logFields).Infof("Copying image tag %d/%d", counter+1, len(srcRepo.TaggedImages))
+
+				// Each copy gets its own report buffer, flushed to stdout as one
+				// atomic write, so that progress lines from concurrent workers
+				// don't interleave.
+				var report bytes.Buffer
+				imageOptions := imageOptions
+				imageOptions.ReportWriter = &report
+
+				copyOnce := func() error {
+					_, err := copy.Image(copyCtx, policyContext, destRef, ref, &imageOptions)
+					return err
+				}
+				if archiveWriter != nil {
+					// docker-archive destinations share a single archive.Writer, whose
+					// underlying tar stream isn't safe for concurrent writes, so
+					// archive copies are serialized regardless of --workers.
+					archiveMu.Lock()
+					err = retryOnError(retryOpts, fmt.Sprintf("Copying tag %s", sourceName), copyOnce)
+					archiveMu.Unlock()
+				} else {
+					err = retryOnError(retryOpts, fmt.Sprintf("Copying tag %s", sourceName), copyOnce)
+				}
+
+				stdoutMu.Lock()
+				report.WriteTo(os.Stdout)
+				stdoutMu.Unlock()
+
+				if err != nil {
+					err = errors.Wrapf(err, fmt.Sprintf("Error copying tag %q", sourceName))
+					countersMu.Lock()
+					copyErrors = append(copyErrors, err)
+					countersMu.Unlock()
+					return err
+				}
+
+				if statePath != "" {
+					var destDigest digest.Digest
+					err := retryOnError(retryOpts, fmt.Sprintf("Computing destination manifest digest for %s", destName), func() error {
+						var err error
+						destDigest, err = manifestDigest(copyCtx, destinationCtx, destRef)
+						return err
+					})
+					if err != nil {
+						// The copy itself already succeeded; a failure to re-fetch the
+						// destination digest for bookkeeping must not be reported as a
+						// sync failure. Just skip recording this tag's state entry.
+						logrus.WithFields(logFields).Warnf("Copied tag %s, but failed to compute destination manifest digest for the state file: %v", sourceName, err)
+					} else {
+						stateMu.Lock()
+						stateByFile[statePath][sourceName] = syncStateEntry{
+							SourceDigest: sourceDigest.String(),
+							DestRef:      destName,
+							DestDigest:   destDigest.String(),
+							Timestamp:    time.Now(),
+						}
+						stateMu.Unlock()
+					}
+				}
+
+				countersMu.Lock()
+				imagesNumber++
+				countersMu.Unlock()
+				return nil
+			})
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		if opts.failFast {
+			return err
+		}
+		msgs := make([]string, 0, len(copyErrors))
+		for _, copyErr := range copyErrors {
+			msgs = append(msgs, copyErr.Error())
+		}
+		return errors.Errorf("%d sync operation(s) failed:\n%s", len(copyErrors), strings.Join(msgs, "\n"))
+	}
+
+	if !opts.dryRun {
+		for statePath, state := range stateByFile {
+			if err := state.save(statePath); err != nil {
+				return errors.Wrapf(err, fmt.Sprintf("Error writing state file %q", statePath))
 			}
-			imagesNumber++
 		}
 	}
 
-	logrus.Infof("Synced %d images from %d sources", imagesNumber, len(srcRepoList))
+	verb := "Synced"
+	if opts.dryRun {
+		verb = "Would sync"
+	}
+	if imagesSkipped > 0 {
+		logrus.Infof("%s %d images from %d sources (%d already in sync, skipped)", verb, imagesNumber, len(srcRepoList), imagesSkipped)
+	} else {
+		logrus.Infof("%s %d images from %d sources", verb, imagesNumber, len(srcRepoList))
+	}
 	return nil
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/pkg/errors"
+)
+
+func TestApplyTagFilters(t *testing.T) {
+	tags := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0", "1.2.0-rc1", "nightly-2021", "latest"}
+
+	for _, c := range []struct {
+		name   string
+		filter tagFilter
+		want   []string
+	}{
+		{
+			name:   "no filter",
+			filter: tagFilter{},
+			want:   tags,
+		},
+		{
+			name:   "tags-regex",
+			filter: tagFilter{TagsRegex: `^1\.`},
+			want:   []string{"1.0.0", "1.2.0", "1.10.0", "1.2.0-rc1"},
+		},
+		{
+			name:   "exclude-tags",
+			filter: tagFilter{ExcludeTags: []string{"^nightly-", "-rc[0-9]+$"}},
+			want:   []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0", "latest"},
+		},
+		{
+			name:   "semver",
+			filter: tagFilter{Semver: ">=1.0.0 <2.0.0"},
+			want:   []string{"1.0.0", "1.2.0", "1.10.0"},
+		},
+		{
+			name:   "keep-last",
+			filter: tagFilter{Semver: ">=0.0.0", KeepLast: 2},
+			want:   []string{"2.0.0", "1.10.0"},
+		},
+		{
+			name: "tags-regex, exclude-tags, semver and keep-last together",
+			filter: tagFilter{
+				TagsRegex:   `^1\.`,
+				ExcludeTags: []string{"-rc[0-9]+$"},
+				Semver:      ">=1.0.0",
+				KeepLast:    1,
+			},
+			want: []string{"1.10.0"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := applyTagFilters(tags, c.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !stringSlicesEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	if _, err := applyTagFilters(tags, tagFilter{TagsRegex: "("}); err == nil {
+		t.Error("expected an error for an invalid tags-regex, got nil")
+	}
+	if _, err := applyTagFilters(tags, tagFilter{ExcludeTags: []string{"("}}); err == nil {
+		t.Error("expected an error for an invalid exclude-tags regex, got nil")
+	}
+	if _, err := applyTagFilters(tags, tagFilter{Semver: "not a constraint"}); err == nil {
+		t.Error("expected an error for an invalid semver constraint, got nil")
+	}
+}
+
+func TestSortTagsByVersionDescending(t *testing.T) {
+	tags := []string{"1.0.0", "banana", "2.0.0", "apple", "1.10.0"}
+	sortTagsByVersionDescending(tags)
+
+	want := []string{"2.0.0", "1.10.0", "1.0.0", "banana", "apple"}
+	if !stringSlicesEqual(tags, want) {
+		t.Errorf("got %v, want %v", tags, want)
+	}
+}
+
+func TestIsRetryableSyncError(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"eof", errors.Wrap(io.EOF, "reading response"), true},
+		{"timeout net.Error", fakeNetError{timeout: true}, true},
+		{"non-timeout net.Error", fakeNetError{}, false},
+		{"unauthorized errcode", errcode.ErrorCodeUnauthorized.WithMessage("nope"), false},
+		{"unknown errcode", errcode.ErrorCodeUnknown.WithMessage("server hiccup"), true},
+		{"manifest unknown message", errors.New("manifest unknown: no such manifest"), false},
+		{"manifest unknown errcode", errcode.ErrorCodeUnknown.WithMessage("manifest unknown: no such manifest"), false},
+		{"manifest unknown errcode.Errors", errcode.Errors{errcode.ErrorCodeUnknown.WithMessage("manifest unknown: no such manifest")}, false},
+		{"policy message", errors.New("Source image rejected by policy"), false},
+		{"too many requests message", errors.New("received 429: too many requests"), true},
+		{"5xx message", errors.New("unexpected HTTP status 503 Service Unavailable"), true},
+		{"unrelated message", errors.New("disk full"), false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryableSyncError(c.err); got != c.want {
+				t.Errorf("isRetryableSyncError(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type fakeNetError struct {
+	timeout bool
+}
+
+func (fakeNetError) Error() string   { return "fake net error" }
+func (e fakeNetError) Timeout() bool { return e.timeout }
+func (fakeNetError) Temporary() bool { return false }